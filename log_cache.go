@@ -1,65 +1,169 @@
 package raft
 
 import (
+	"runtime"
 	"sync"
+	"sync/atomic"
 )
 
-// LogCache wraps a logstore with a ring buffer providing fast access to the
-// last n raft log entries.
+// CachePolicy is a pluggable storage and eviction strategy for LogCache. Get,
+// Put and LastEntry must be safe for concurrent use. Reset drops everything
+// cached, e.g. after a DeleteRange.
+type CachePolicy interface {
+	Get(logidx uint64) (*Log, bool)
+	Put(logs []*Log)
+	LastEntry() (*Log, bool)
+	Reset()
+
+	// PutBehindTip caches logs that are not known to be the newest writes
+	// (e.g. prefetched entries warmed for a lagging reader) without
+	// disturbing what LastEntry considers the current tip.
+	PutBehindTip(logs []*Log)
+
+	// snapshot returns a point-in-time copy of every entry currently held by
+	// the policy, keyed by index. It backs LogCache.LogSnapshot. Because it
+	// is unexported, only types in this package can implement CachePolicy.
+	snapshot() map[uint64]*Log
+}
+
+// LogCache wraps a logstore with a pluggable CachePolicy providing fast
+// access to recent raft log entries. NewLogCache selects the ring-buffer
+// policy for backwards compatibility; callers that want a different
+// eviction strategy (e.g. the SIEVE or map policy) can use
+// NewLogCacheWithPolicy instead. Policies outside this package cannot be
+// plugged in, since CachePolicy has an unexported method.
 type LogCache struct {
-	store      LogStore
-	cache      []*Log
-	current    int
-	lastlogidx uint64
-	l          sync.RWMutex
+	store  LogStore
+	policy CachePolicy
+
+	snapMu   sync.Mutex
+	snapCond *sync.Cond
+	snaps    map[*LogSnapshot]struct{}
+
+	prefetch   int
+	missMu     sync.Mutex
+	missStreak []uint64
+	prefetchCh chan prefetchRequest
+
+	negCache   *negativeIndexCache
+	fetchGroup logCallGroup
+
+	prefetchWG sync.WaitGroup
 }
 
-func NewLogCache(capacity int, logstore LogStore) *LogCache {
-	return &LogCache{
-		cache: make([]*Log, capacity),
-		store: logstore,
+// Close stops any background goroutine the cache owns (a map policy's
+// evictor, the prefetcher's warm-ahead loop) and waits for them to exit.
+// Callers that construct a LogCache with NewMapCachePolicy or
+// NewLogCacheWithPrefetch must call Close once the cache is no longer
+// needed, or those goroutines (and everything they hold onto) leak for the
+// life of the process.
+func (c *LogCache) Close() {
+	if cl, ok := c.policy.(interface{ Close() }); ok {
+		cl.Close()
+	}
+	if c.prefetchCh != nil {
+		close(c.prefetchCh)
 	}
+	c.prefetchWG.Wait()
 }
 
-func (c *LogCache) getLogFromCache(logidx uint64) (*Log, bool) {
-	c.l.RLock()
-	defer c.l.RUnlock()
+// defaultNegativeCacheCapacity bounds how many "not found" indices
+// negCache remembers before evicting the oldest. It is small and fixed
+// since a negative hit only ever saves a single store round trip.
+const defaultNegativeCacheCapacity = 1024
 
-	// 'last' is the index of the element we cached last,
-	// its raft log index is 'lastlogidx'
-	last := (c.current - 1)
-	m := last - int(c.lastlogidx-logidx)
+func NewLogCache(capacity int, logstore LogStore) *LogCache {
+	return NewLogCacheWithPolicy(logstore, NewRingCachePolicy(capacity))
+}
 
-	// See https://golang.org/issue/448 for why (m % n) is not enough.
-	n := len(c.cache)
-	log := c.cache[((m%n)+n)%n]
-	if log == nil {
-		return nil, false
+// NewLogCacheWithPolicy builds a LogCache backed by an arbitrary CachePolicy,
+// for callers that want eviction behavior other than the default ring
+// buffer.
+func NewLogCacheWithPolicy(logstore LogStore, policy CachePolicy) *LogCache {
+	c := &LogCache{
+		store:    logstore,
+		policy:   policy,
+		snaps:    make(map[*LogSnapshot]struct{}),
+		negCache: newNegativeIndexCache(defaultNegativeCacheCapacity),
 	}
-	// If the index does not match, cacheLog’s expected access pattern was
-	// violated and we need to fall back to reading from the LogStore.
-	return log, log.Index == logidx
+	c.snapCond = sync.NewCond(&c.snapMu)
+	return c
 }
 
-// cacheLogs should be called with strictly monotonically increasing logidx
-// values, otherwise the cache will not be effective.
-func (c *LogCache) cacheLogs(logs []*Log) {
-	c.l.Lock()
-	defer c.l.Unlock()
-
-	for _, l := range logs {
-		c.cache[c.current] = l
-		c.lastlogidx = l.Index
-		c.current = (c.current + 1) % len(c.cache)
+// NewLogCacheWithPrefetch builds a LogCache that, on detecting a sequential
+// read-ahead pattern (three consecutive GetLog misses at increasing
+// indices), warms the cache with the next prefetch entries from the
+// underlying store. This targets a leader streaming AppendEntries to a slow
+// follower, where every miss would otherwise cost a synchronous read against
+// the store.
+func NewLogCacheWithPrefetch(capacity, prefetch int, store LogStore) *LogCache {
+	// The ring policy's indexing is relative to lastlogidx/current, so it
+	// can't absorb the out-of-order, behind-the-tip writes a prefetch
+	// produces; the map policy has no such constraint.
+	c := NewLogCacheWithPolicy(store, NewMapCachePolicy(capacity))
+	c.prefetch = prefetch
+	if prefetch > 0 {
+		c.prefetchCh = make(chan prefetchRequest, 1)
+		c.prefetchWG.Add(1)
+		go func() {
+			defer c.prefetchWG.Done()
+			c.prefetchLoop()
+		}()
 	}
+	return c
 }
 
+// GetLog checks the cache, then negCache for a remembered miss, and only
+// then reaches the store; concurrent misses for the same index are
+// coalesced through fetchGroup so exactly one store.GetLog is issued.
 func (c *LogCache) GetLog(logidx uint64, log *Log) error {
-	if cached, ok := c.getLogFromCache(logidx); ok {
+	if cached, ok := c.policy.Get(logidx); ok {
 		*log = *cached
 		return nil
 	}
-	return c.store.GetLog(logidx, log)
+	if c.negCache.Contains(logidx) {
+		return ErrLogNotFound
+	}
+
+	fetched, err := c.fetchGroup.Do(logidx, func() (*Log, error) {
+		l := new(Log)
+		if ferr := c.store.GetLog(logidx, l); ferr != nil {
+			return nil, ferr
+		}
+		return l, nil
+	})
+	if err != nil {
+		if err == ErrLogNotFound {
+			c.negCache.Add(logidx)
+		}
+		return err
+	}
+
+	*log = *fetched
+	if c.prefetch > 0 {
+		c.noteMiss(logidx)
+	}
+	return nil
+}
+
+// LastLog is a fast-path accessor for the most recently stored entry,
+// avoiding a cache lookup (and a round trip to the store on a miss) for the
+// "fetch the tip" pattern that dominates steady-state replication.
+func (c *LogCache) LastLog(log *Log) error {
+	if last, ok := c.policy.LastEntry(); ok {
+		*log = *last
+		return nil
+	}
+
+	lastIdx, err := c.store.LastIndex()
+	if err != nil {
+		return err
+	}
+	if lastIdx == 0 {
+		*log = Log{}
+		return nil
+	}
+	return c.store.GetLog(lastIdx, log)
 }
 
 func (c *LogCache) StoreLog(log *Log) error {
@@ -67,7 +171,14 @@ func (c *LogCache) StoreLog(log *Log) error {
 }
 
 func (c *LogCache) StoreLogs(logs []*Log) error {
-	c.cacheLogs(logs)
+	c.policy.Put(logs)
+	// Indices just written can no longer be "not found"; without this a
+	// stale negative-cache entry (from a GetLog that raced ahead of the
+	// write) would keep shadowing a real entry until unrelated churn FIFO-
+	// evicted it out of negCache.
+	if len(logs) > 0 {
+		c.negCache.InvalidateRange(logs[0].Index, logs[len(logs)-1].Index)
+	}
 	return c.store.StoreLogs(logs)
 }
 
@@ -79,13 +190,345 @@ func (c *LogCache) LastIndex() (uint64, error) {
 	return c.store.LastIndex()
 }
 
+// DeleteRange clears the cache and removes the given range of logs from the
+// underlying store. If an outstanding LogSnapshot observed an index at or
+// above min, the store delete is deferred until that snapshot is Closed, so
+// a reader holding a snapshot can never have an entry it already saw vanish
+// from underneath it. The wait-for-snapshots check and the cache reset share
+// snapMu with LogSnapshot's clone-and-register step, so the two can never
+// interleave: either LogSnapshot finishes registering before DeleteRange
+// checks c.snaps, or DeleteRange's check (and therefore its decision that
+// the range is safe to delete) happens-before the snapshot exists at all.
 func (c *LogCache) DeleteRange(min, max uint64) error {
-	c.l.Lock()
-	defer c.l.Unlock()
-
-	c.lastlogidx = 0
-	c.current = 0
-	c.cache = make([]*Log, len(c.cache))
+	c.snapMu.Lock()
+	for c.anyoneCoversLocked(min) {
+		c.snapCond.Wait()
+	}
+	c.policy.Reset()
+	c.negCache.InvalidateRange(min, max)
+	c.snapMu.Unlock()
 
 	return c.store.DeleteRange(min, max)
-}
\ No newline at end of file
+}
+
+func (c *LogCache) anyoneCoversLocked(min uint64) bool {
+	for snap := range c.snaps {
+		if snap.lastlogidx >= min {
+			return true
+		}
+	}
+	return false
+}
+
+// LogSnapshot returns an immutable, point-in-time view of the cache that can
+// be handed to a background goroutine (e.g. a Ready handler or a replication
+// flow building MsgApp batches), letting the caller read entries
+// concurrently with new StoreLogs/DeleteRange calls from the leader loop.
+// The entries are copied out of the policy once, so later mutations of the
+// live cache cannot affect the snapshot.
+//
+// Callers MUST call Close, ideally via defer, as soon as they're done with
+// the snapshot:
+//
+//	snap := cache.LogSnapshot()
+//	defer snap.Close()
+//
+// DeleteRange blocks on any outstanding snapshot whose LastIndex is at or
+// above the truncation point, so a leaked snapshot wedges every future
+// DeleteRange (i.e. all log compaction) on this cache indefinitely. The
+// returned snapshot carries a finalizer that calls Close, but the cache
+// keeps every outstanding snapshot in c.snaps until Close, so a leaked
+// snapshot stays reachable and its finalizer does not release it.
+func (c *LogCache) LogSnapshot() *LogSnapshot {
+	c.snapMu.Lock()
+	entries := c.policy.snapshot()
+
+	var lastlogidx uint64
+	if last, ok := c.policy.LastEntry(); ok {
+		lastlogidx = last.Index
+	}
+
+	snap := &LogSnapshot{
+		owner:      c,
+		store:      c.store,
+		entries:    entries,
+		lastlogidx: lastlogidx,
+	}
+	c.snaps[snap] = struct{}{}
+	c.snapMu.Unlock()
+
+	runtime.SetFinalizer(snap, (*LogSnapshot).Close)
+
+	return snap
+}
+
+// release drops the snapshot's pin on the cache and wakes any DeleteRange
+// calls that were waiting on it.
+func (c *LogCache) release(snap *LogSnapshot) {
+	c.snapMu.Lock()
+	delete(c.snaps, snap)
+	c.snapCond.Broadcast()
+	c.snapMu.Unlock()
+}
+
+// LogSnapshot is an immutable, lock-free view over a LogCache as observed at
+// the time LogSnapshot was called. It must be released with Close once the
+// caller is done reading from it.
+type LogSnapshot struct {
+	owner *LogCache
+	store LogStore
+
+	entries    map[uint64]*Log
+	lastlogidx uint64
+
+	closeOnce sync.Once
+}
+
+// GetLog mirrors LogCache.GetLog but reads only the cloned entries and falls
+// back to the underlying store for entries the snapshot did not observe.
+func (s *LogSnapshot) GetLog(logidx uint64, log *Log) error {
+	if cached, ok := s.entries[logidx]; ok {
+		*log = *cached
+		return nil
+	}
+	return s.store.GetLog(logidx, log)
+}
+
+// FirstIndex returns the underlying store's current first index, read live
+// rather than fixed at the time LogSnapshot was called.
+func (s *LogSnapshot) FirstIndex() (uint64, error) {
+	return s.store.FirstIndex()
+}
+
+// LastIndex returns the last index observed by this snapshot, which is fixed
+// at the time LogSnapshot was called rather than tracking the live store.
+func (s *LogSnapshot) LastIndex() (uint64, error) {
+	return s.lastlogidx, nil
+}
+
+// Range calls fn for every log in [min, max], stopping early if fn returns
+// false. Entries outside the cloned set are filled in from the snapshot's
+// underlying store.
+func (s *LogSnapshot) Range(min, max uint64, fn func(log *Log) bool) error {
+	for idx := min; idx <= max; idx++ {
+		log := new(Log)
+		if err := s.GetLog(idx, log); err != nil {
+			return err
+		}
+		if !fn(log) {
+			break
+		}
+	}
+	return nil
+}
+
+// Close releases the snapshot's pin on its owning LogCache, unblocking any
+// DeleteRange call that was waiting on it. It is safe to call more than
+// once.
+func (s *LogSnapshot) Close() {
+	s.closeOnce.Do(func() {
+		runtime.SetFinalizer(s, nil)
+		if s.owner != nil {
+			s.owner.release(s)
+		}
+	})
+}
+
+// ringCachePolicy is the original fixed-capacity ring buffer policy, kept as
+// the default for backwards compatibility. It assumes StoreLogs is called
+// with strictly monotonically increasing indices.
+type ringCachePolicy struct {
+	cache      []*Log
+	current    int
+	lastlogidx uint64
+	l          sync.RWMutex
+}
+
+// NewRingCachePolicy returns the classic fixed-size ring buffer CachePolicy.
+func NewRingCachePolicy(capacity int) CachePolicy {
+	return &ringCachePolicy{cache: make([]*Log, capacity)}
+}
+
+func (r *ringCachePolicy) Get(logidx uint64) (*Log, bool) {
+	r.l.RLock()
+	defer r.l.RUnlock()
+
+	// 'last' is the index of the element we cached last,
+	// its raft log index is 'lastlogidx'
+	last := r.current - 1
+	m := last - int(r.lastlogidx-logidx)
+
+	// See https://golang.org/issue/448 for why (m % n) is not enough.
+	n := len(r.cache)
+	log := r.cache[((m%n)+n)%n]
+	if log == nil {
+		return nil, false
+	}
+	// If the index does not match, the expected access pattern was violated
+	// and the caller needs to fall back to reading from the LogStore.
+	return log, log.Index == logidx
+}
+
+func (r *ringCachePolicy) Put(logs []*Log) {
+	r.l.Lock()
+	defer r.l.Unlock()
+
+	for _, l := range logs {
+		r.cache[r.current] = l
+		r.lastlogidx = l.Index
+		r.current = (r.current + 1) % len(r.cache)
+	}
+}
+
+// PutBehindTip is a no-op: the ring's Get math assumes every cached entry is
+// reachable by walking back from lastlogidx/current, so absorbing
+// out-of-order prefetched entries would corrupt lookups for every index, not
+// just the tip. Callers that want prefetch support should use a policy that
+// isn't tip-relative, e.g. mapCachePolicy.
+func (r *ringCachePolicy) PutBehindTip(logs []*Log) {}
+
+func (r *ringCachePolicy) LastEntry() (*Log, bool) {
+	r.l.RLock()
+	defer r.l.RUnlock()
+
+	n := len(r.cache)
+	log := r.cache[((r.current-1)%n+n)%n]
+	if log == nil {
+		return nil, false
+	}
+	return log, true
+}
+
+func (r *ringCachePolicy) Reset() {
+	r.l.Lock()
+	defer r.l.Unlock()
+
+	r.lastlogidx = 0
+	r.current = 0
+	r.cache = make([]*Log, len(r.cache))
+}
+
+func (r *ringCachePolicy) snapshot() map[uint64]*Log {
+	r.l.RLock()
+	defer r.l.RUnlock()
+
+	entries := make(map[uint64]*Log)
+	for _, log := range r.cache {
+		if log != nil {
+			entries[log.Index] = log
+		}
+	}
+	return entries
+}
+
+// mapCachePolicy backs the cache with a sync.Map keyed by log index, bounded
+// by a background evictor, plus an atomically-updated lastEntry for the
+// "fetch the tip" fast path. Unlike ringCachePolicy it lets concurrent
+// GetLog/StoreLogs calls on disjoint indices proceed without contending on a
+// single mutex.
+type mapCachePolicy struct {
+	capacity  int
+	entries   sync.Map // uint64 -> *Log
+	size      int32
+	order     chan uint64
+	lastEntry atomic.Value // holds *Log
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+// NewMapCachePolicy returns a CachePolicy backed by a sync.Map, suited to
+// write-heavy leaders where ring buffer lock contention becomes a hotspot.
+// The returned policy owns a background evictor goroutine; callers must
+// arrange for LogCache.Close to be called once the cache is no longer
+// needed, or the evictor leaks.
+func NewMapCachePolicy(capacity int) CachePolicy {
+	m := &mapCachePolicy{
+		capacity: capacity,
+		order:    make(chan uint64, capacity),
+		done:     make(chan struct{}),
+	}
+	go m.evictLoop()
+	return m
+}
+
+// evictLoop is the background evictor that bounds the cache to capacity. It
+// consumes indices in the order they were cached and drops the oldest ones
+// once size exceeds capacity, so Get/Put never have to pay for eviction
+// bookkeeping on their own path.
+func (m *mapCachePolicy) evictLoop() {
+	defer close(m.done)
+
+	var pending []uint64
+	for idx := range m.order {
+		pending = append(pending, idx)
+		for atomic.LoadInt32(&m.size) > int32(m.capacity) && len(pending) > 0 {
+			oldest := pending[0]
+			pending = pending[1:]
+			if _, ok := m.entries.Load(oldest); ok {
+				m.entries.Delete(oldest)
+				atomic.AddInt32(&m.size, -1)
+			}
+		}
+	}
+}
+
+// Close stops evictLoop and waits for it to exit. It is safe to call more
+// than once.
+func (m *mapCachePolicy) Close() {
+	m.closeOnce.Do(func() {
+		close(m.order)
+	})
+	<-m.done
+}
+
+func (m *mapCachePolicy) Get(logidx uint64) (*Log, bool) {
+	if v, ok := m.entries.Load(logidx); ok {
+		return v.(*Log), true
+	}
+	return nil, false
+}
+
+func (m *mapCachePolicy) Put(logs []*Log) {
+	for _, l := range logs {
+		m.entries.Store(l.Index, l)
+		m.lastEntry.Store(l)
+		atomic.AddInt32(&m.size, 1)
+		m.order <- l.Index
+	}
+}
+
+// PutBehindTip caches logs without advancing lastEntry, since a prefetcher
+// warming entries for a lagging reader has no bearing on what the tip is.
+func (m *mapCachePolicy) PutBehindTip(logs []*Log) {
+	for _, l := range logs {
+		m.entries.Store(l.Index, l)
+		atomic.AddInt32(&m.size, 1)
+		m.order <- l.Index
+	}
+}
+
+func (m *mapCachePolicy) LastEntry() (*Log, bool) {
+	if v, ok := m.lastEntry.Load().(*Log); ok && v != nil {
+		return v, true
+	}
+	return nil, false
+}
+
+func (m *mapCachePolicy) Reset() {
+	m.entries.Range(func(k, _ interface{}) bool {
+		m.entries.Delete(k)
+		atomic.AddInt32(&m.size, -1)
+		return true
+	})
+	m.lastEntry.Store((*Log)(nil))
+}
+
+func (m *mapCachePolicy) snapshot() map[uint64]*Log {
+	entries := make(map[uint64]*Log)
+	m.entries.Range(func(k, v interface{}) bool {
+		entries[k.(uint64)] = v.(*Log)
+		return true
+	})
+	return entries
+}
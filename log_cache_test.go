@@ -0,0 +1,325 @@
+package raft
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// fakeLogStore is a minimal in-memory LogStore for exercising LogCache
+// without a real backing store.
+type fakeLogStore struct {
+	mu         sync.Mutex
+	logs       map[uint64]*Log
+	first, last uint64
+	getCalls   int32
+}
+
+func newFakeLogStore() *fakeLogStore {
+	return &fakeLogStore{logs: make(map[uint64]*Log)}
+}
+
+func (f *fakeLogStore) GetLog(index uint64, log *Log) error {
+	atomic.AddInt32(&f.getCalls, 1)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	l, ok := f.logs[index]
+	if !ok {
+		return ErrLogNotFound
+	}
+	*log = *l
+	return nil
+}
+
+func (f *fakeLogStore) StoreLog(log *Log) error {
+	return f.StoreLogs([]*Log{log})
+}
+
+func (f *fakeLogStore) StoreLogs(logs []*Log) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, l := range logs {
+		f.logs[l.Index] = l
+		if f.first == 0 || l.Index < f.first {
+			f.first = l.Index
+		}
+		if l.Index > f.last {
+			f.last = l.Index
+		}
+	}
+	return nil
+}
+
+func (f *fakeLogStore) FirstIndex() (uint64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.first, nil
+}
+
+func (f *fakeLogStore) LastIndex() (uint64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.last, nil
+}
+
+func (f *fakeLogStore) DeleteRange(min, max uint64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for idx := min; idx <= max; idx++ {
+		delete(f.logs, idx)
+	}
+	return nil
+}
+
+func mkLog(idx uint64) *Log {
+	return &Log{Index: idx, Term: 1, Data: []byte("x")}
+}
+
+// TestLogCache_PrefetchDoesNotClobberTip is a regression test: prefetching
+// old entries for a lagging reader must not make LastLog start reporting a
+// stale index.
+func TestLogCache_PrefetchDoesNotClobberTip(t *testing.T) {
+	store := newFakeLogStore()
+	for i := uint64(1); i <= 100; i++ {
+		if err := store.StoreLog(mkLog(i)); err != nil {
+			t.Fatalf("StoreLog: %v", err)
+		}
+	}
+
+	c := NewLogCacheWithPrefetch(16, 10, store)
+	defer c.Close()
+	c.StoreLog(mkLog(100))
+
+	var got Log
+	for _, idx := range []uint64{1, 2, 3} {
+		if err := c.GetLog(idx, &got); err != nil {
+			t.Fatalf("GetLog(%d): %v", idx, err)
+		}
+	}
+
+	var last Log
+	if err := c.LastLog(&last); err != nil {
+		t.Fatalf("LastLog: %v", err)
+	}
+	if last.Index != 100 {
+		t.Fatalf("LastLog returned index %d after prefetch, want 100 (tip clobbered)", last.Index)
+	}
+}
+
+// TestLogCache_NegativeCacheInvalidatedByStoreLogs is a regression test:
+// writing an index must clear any stale "not found" memory for it.
+func TestLogCache_NegativeCacheInvalidatedByStoreLogs(t *testing.T) {
+	store := newFakeLogStore()
+	c := NewLogCacheWithPolicy(store, NewRingCachePolicy(2))
+
+	var got Log
+	if err := c.GetLog(5, &got); err != ErrLogNotFound {
+		t.Fatalf("GetLog(5) before write = %v, want ErrLogNotFound", err)
+	}
+
+	for _, idx := range []uint64{5, 6, 7} {
+		if err := c.StoreLog(mkLog(idx)); err != nil {
+			t.Fatalf("StoreLog(%d): %v", idx, err)
+		}
+	}
+
+	if err := c.GetLog(5, &got); err != nil {
+		t.Fatalf("GetLog(5) after write = %v, want nil", err)
+	}
+	if got.Index != 5 {
+		t.Fatalf("GetLog(5) returned index %d", got.Index)
+	}
+}
+
+// gatedLogStore wraps a LogStore and holds every GetLog call at the door
+// until entered reaches n. TestLogCache_SingleflightCoalescesMisses uses
+// this to keep the winning call blocked until every racing goroutine has
+// bumped entered, which each does just before calling LogCache.GetLog, so
+// the goroutines join the in-flight call while it is held open.
+type gatedLogStore struct {
+	LogStore
+	entered *int32
+	n       int32
+}
+
+func (g *gatedLogStore) GetLog(index uint64, log *Log) error {
+	for atomic.LoadInt32(g.entered) < g.n {
+		runtime.Gosched()
+	}
+	return g.LogStore.GetLog(index, log)
+}
+
+// TestLogCache_SingleflightCoalescesMisses checks that N goroutines racing
+// to fetch the same uncached index produce exactly one store.GetLog call.
+func TestLogCache_SingleflightCoalescesMisses(t *testing.T) {
+	store := newFakeLogStore()
+	store.StoreLog(mkLog(42))
+
+	const n = 50
+	var entered int32
+	gated := &gatedLogStore{LogStore: store, entered: &entered, n: n}
+
+	c := NewLogCacheWithPolicy(gated, NewRingCachePolicy(1))
+
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer wg.Done()
+			atomic.AddInt32(&entered, 1)
+			var log Log
+			if err := c.GetLog(42, &log); err != nil {
+				t.Errorf("GetLog(42): %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if calls := atomic.LoadInt32(&store.getCalls); calls != 1 {
+		t.Fatalf("store.GetLog called %d times, want 1", calls)
+	}
+}
+
+// TestLogCache_ConcurrentAccess stresses GetLog/StoreLogs/LogSnapshot
+// together across every CachePolicy, under -race, then runs DeleteRange
+// once they have finished.
+func TestLogCache_ConcurrentAccess(t *testing.T) {
+	policies := map[string]func() CachePolicy{
+		"ring":  func() CachePolicy { return NewRingCachePolicy(32) },
+		"map":   func() CachePolicy { return NewMapCachePolicy(32) },
+		"sieve": func() CachePolicy { return NewSieveCachePolicy(32) },
+	}
+
+	for name, newPolicy := range policies {
+		t.Run(name, func(t *testing.T) {
+			store := newFakeLogStore()
+			c := NewLogCacheWithPolicy(store, newPolicy())
+			defer c.Close()
+
+			var wg sync.WaitGroup
+			stop := make(chan struct{})
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for idx := uint64(1); idx <= 500; idx++ {
+					if err := c.StoreLog(mkLog(idx)); err != nil {
+						t.Errorf("StoreLog: %v", err)
+					}
+				}
+				close(stop)
+			}()
+
+			for i := 0; i < 4; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					var log Log
+					for idx := uint64(1); ; idx++ {
+						select {
+						case <-stop:
+							return
+						default:
+						}
+						_ = c.GetLog(idx%500+1, &log)
+					}
+				}()
+			}
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					select {
+					case <-stop:
+						return
+					default:
+					}
+					snap := c.LogSnapshot()
+					snap.Close()
+				}
+			}()
+
+			wg.Wait()
+			if err := c.DeleteRange(1, 500); err != nil {
+				t.Fatalf("DeleteRange: %v", err)
+			}
+		})
+	}
+}
+
+// TestSieveCachePolicy_EvictionOrder pins down SIEVE's exact eviction
+// behavior: a visited entry survives one sweep by having its visited bit
+// cleared, and the hand resumes from where the previous sweep stopped
+// rather than restarting at the tail each time.
+func TestSieveCachePolicy_EvictionOrder(t *testing.T) {
+	p := NewSieveCachePolicy(3)
+	p.Put([]*Log{mkLog(1)})
+	p.Put([]*Log{mkLog(2)})
+	p.Put([]*Log{mkLog(3)})
+
+	// Mark 1 (the tail) visited so the first sweep must skip over it.
+	if _, ok := p.Get(1); !ok {
+		t.Fatalf("Get(1): not found")
+	}
+
+	// Cache is full; this insert must evict exactly one entry. 1 is
+	// protected by its visited bit, so 2 (the next node toward head) goes.
+	p.Put([]*Log{mkLog(4)})
+
+	// This also marks 1, 3 and 4 visited, which matters for the next
+	// eviction below.
+	for _, idx := range []uint64{1, 3, 4} {
+		if _, ok := p.Get(idx); !ok {
+			t.Fatalf("Get(%d) missing after first eviction, want present", idx)
+		}
+	}
+	if _, ok := p.Get(2); ok {
+		t.Fatalf("Get(2) present after first eviction, want evicted")
+	}
+
+	// Force a second eviction. The hand resumes from where the first sweep
+	// stopped (at 3) rather than restarting at the tail: it clears the
+	// visited bits of 3 and 4, wraps around to the tail to clear 1's, and
+	// evicts 3 on its second visit. Restarting at the tail would evict 1.
+	p.Put([]*Log{mkLog(5)})
+
+	for _, idx := range []uint64{1, 4, 5} {
+		if _, ok := p.Get(idx); !ok {
+			t.Fatalf("Get(%d) missing after second eviction, want present", idx)
+		}
+	}
+	if _, ok := p.Get(3); ok {
+		t.Fatalf("Get(3) present after second eviction, want evicted")
+	}
+}
+
+// BenchmarkLogCache_ConcurrentGetStore exercises concurrent GetLog/StoreLogs
+// against the map-backed policy introduced to remove ring-buffer lock
+// contention.
+func BenchmarkLogCache_ConcurrentGetStore(b *testing.B) {
+	store := newFakeLogStore()
+	for i := uint64(1); i <= 1000; i++ {
+		store.StoreLog(mkLog(i))
+	}
+	c := NewLogCacheWithPolicy(store, NewMapCachePolicy(1000))
+	defer c.Close()
+	for i := uint64(1); i <= 1000; i++ {
+		c.StoreLog(mkLog(i))
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		var log Log
+		idx := uint64(1)
+		for pb.Next() {
+			idx = idx%1000 + 1
+			_ = c.GetLog(idx, &log)
+		}
+	})
+}
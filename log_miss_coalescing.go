@@ -0,0 +1,106 @@
+package raft
+
+import "sync"
+
+// negativeIndexCache remembers recently-observed "not found" indices so
+// repeated GetLog misses (e.g. below FirstIndex after a snapshot or
+// truncation) don't keep paying for a store round trip. Bounded to a fixed
+// capacity with FIFO eviction; a negative hit only saves a single read, so
+// it doesn't need to be exact.
+type negativeIndexCache struct {
+	mu       sync.Mutex
+	capacity int
+	set      map[uint64]struct{}
+	order    []uint64
+}
+
+func newNegativeIndexCache(capacity int) *negativeIndexCache {
+	return &negativeIndexCache{
+		capacity: capacity,
+		set:      make(map[uint64]struct{}),
+	}
+}
+
+func (n *negativeIndexCache) Contains(idx uint64) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	_, ok := n.set[idx]
+	return ok
+}
+
+func (n *negativeIndexCache) Add(idx uint64) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if _, ok := n.set[idx]; ok {
+		return
+	}
+	if len(n.order) >= n.capacity {
+		oldest := n.order[0]
+		n.order = n.order[1:]
+		delete(n.set, oldest)
+	}
+	n.set[idx] = struct{}{}
+	n.order = append(n.order, idx)
+}
+
+// InvalidateRange drops every remembered index in [min, max]. Both
+// DeleteRange and StoreLogs call this, since either can make a previously
+// "not found" index valid again.
+func (n *negativeIndexCache) InvalidateRange(min, max uint64) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	kept := n.order[:0]
+	for _, idx := range n.order {
+		if idx >= min && idx <= max {
+			delete(n.set, idx)
+			continue
+		}
+		kept = append(kept, idx)
+	}
+	n.order = kept
+}
+
+// logCallGroup coalesces concurrent GetLog misses for the same index,
+// singleflight-style: N goroutines racing for the same uncached entry issue
+// one store.GetLog between them. The in-flight entry is removed on
+// completion regardless of outcome, so a transient error can't poison later
+// lookups.
+type logCallGroup struct {
+	mu    sync.Mutex
+	calls map[uint64]*logCall
+}
+
+type logCall struct {
+	wg  sync.WaitGroup
+	log *Log
+	err error
+}
+
+func (g *logCallGroup) Do(logidx uint64, fn func() (*Log, error)) (*Log, error) {
+	g.mu.Lock()
+	if c, ok := g.calls[logidx]; ok {
+		g.mu.Unlock()
+		c.wg.Wait()
+		return c.log, c.err
+	}
+
+	c := new(logCall)
+	c.wg.Add(1)
+	if g.calls == nil {
+		g.calls = make(map[uint64]*logCall)
+	}
+	g.calls[logidx] = c
+	g.mu.Unlock()
+
+	c.log, c.err = fn()
+	c.wg.Done()
+
+	g.mu.Lock()
+	delete(g.calls, logidx)
+	g.mu.Unlock()
+
+	return c.log, c.err
+}
@@ -0,0 +1,83 @@
+package raft
+
+// BatchLogStore is an optional bulk-read interface for a LogStore. Stores
+// that don't implement it get a GetLog-per-index fallback.
+type BatchLogStore interface {
+	// GetLogRange fills out[i] with the log at index min+i, leaving entries
+	// the store doesn't have nil.
+	GetLogRange(min, max uint64, out []*Log) error
+}
+
+// prefetchRequest is an inclusive index range to warm into the cache.
+type prefetchRequest struct {
+	min, max uint64
+}
+
+// noteMiss schedules a prefetch once three consecutive misses at increasing
+// indices have been observed.
+func (c *LogCache) noteMiss(logidx uint64) {
+	c.missMu.Lock()
+	if n := len(c.missStreak); n > 0 && c.missStreak[n-1]+1 == logidx {
+		c.missStreak = append(c.missStreak, logidx)
+	} else {
+		c.missStreak = c.missStreak[:0]
+		c.missStreak = append(c.missStreak, logidx)
+	}
+	streak := len(c.missStreak)
+	c.missMu.Unlock()
+
+	if streak < 3 {
+		return
+	}
+
+	min := logidx + 1
+	max := logidx + uint64(c.prefetch)
+	select {
+	case c.prefetchCh <- prefetchRequest{min: min, max: max}:
+	default:
+		// A prefetch is already in flight; the next miss in the streak will
+		// try again once it drains.
+	}
+}
+
+// prefetchLoop serves prefetchCh, warming the cache ahead of a reader doing
+// sequential GetLog calls.
+func (c *LogCache) prefetchLoop() {
+	for req := range c.prefetchCh {
+		c.runPrefetch(req.min, req.max)
+	}
+}
+
+func (c *LogCache) runPrefetch(min, max uint64) {
+	if max < min {
+		return
+	}
+	n := int(max-min) + 1
+
+	logs := make([]*Log, 0, n)
+	if batch, ok := c.store.(BatchLogStore); ok {
+		out := make([]*Log, n)
+		if err := batch.GetLogRange(min, max, out); err != nil {
+			return
+		}
+		for _, l := range out {
+			if l != nil {
+				logs = append(logs, l)
+			}
+		}
+	} else {
+		for idx := min; idx <= max; idx++ {
+			l := new(Log)
+			if err := c.store.GetLog(idx, l); err != nil {
+				break
+			}
+			logs = append(logs, l)
+		}
+	}
+
+	// PutBehindTip, not Put: these entries were warmed for a lagging reader
+	// and are not the newest writes, so they must not clobber LastEntry.
+	if len(logs) > 0 {
+		c.policy.PutBehindTip(logs)
+	}
+}
@@ -0,0 +1,164 @@
+package raft
+
+import "sync"
+
+// sieveNode is a single entry in a sieveCachePolicy's doubly-linked list.
+type sieveNode struct {
+	key     uint64
+	log     *Log
+	visited bool
+	prev    *sieveNode
+	next    *sieveNode
+}
+
+// sieveCachePolicy implements SIEVE (https://sievecache.com): a doubly-linked
+// list with a single visited bit per entry and a hand that sweeps from tail
+// to head on eviction. Inserts go at the head; a hit just sets the visited
+// bit, so unlike LRU a cache hit never mutates the list.
+type sieveCachePolicy struct {
+	mu       sync.Mutex
+	capacity int
+	index    map[uint64]*sieveNode
+	head     *sieveNode // most recently inserted
+	tail     *sieveNode // candidate for eviction
+	hand     *sieveNode
+	last     *Log // most recent entry through Put, independent of list position
+}
+
+// NewSieveCachePolicy returns a CachePolicy implementing the SIEVE eviction
+// algorithm, an alternative to the default ring buffer.
+func NewSieveCachePolicy(capacity int) CachePolicy {
+	return &sieveCachePolicy{
+		capacity: capacity,
+		index:    make(map[uint64]*sieveNode, capacity),
+	}
+}
+
+func (s *sieveCachePolicy) Get(logidx uint64) (*Log, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n, ok := s.index[logidx]
+	if !ok {
+		return nil, false
+	}
+	n.visited = true
+	return n.log, true
+}
+
+func (s *sieveCachePolicy) Put(logs []*Log) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, l := range logs {
+		s.putLocked(l)
+		s.last = l
+	}
+}
+
+// PutBehindTip caches logs without updating last, since a prefetcher warming
+// entries for a lagging reader has no bearing on what the tip is.
+func (s *sieveCachePolicy) PutBehindTip(logs []*Log) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, l := range logs {
+		s.putLocked(l)
+	}
+}
+
+func (s *sieveCachePolicy) putLocked(l *Log) {
+	if n, ok := s.index[l.Index]; ok {
+		n.log = l
+		n.visited = true
+		return
+	}
+	if len(s.index) >= s.capacity {
+		s.evictLocked()
+	}
+	n := &sieveNode{key: l.Index, log: l}
+	s.insertAtHeadLocked(n)
+	s.index[l.Index] = n
+}
+
+func (s *sieveCachePolicy) LastEntry() (*Log, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.last == nil {
+		return nil, false
+	}
+	return s.last, true
+}
+
+func (s *sieveCachePolicy) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.index = make(map[uint64]*sieveNode, s.capacity)
+	s.head = nil
+	s.tail = nil
+	s.hand = nil
+	s.last = nil
+}
+
+func (s *sieveCachePolicy) snapshot() map[uint64]*Log {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries := make(map[uint64]*Log, len(s.index))
+	for k, n := range s.index {
+		entries[k] = n.log
+	}
+	return entries
+}
+
+func (s *sieveCachePolicy) insertAtHeadLocked(n *sieveNode) {
+	n.next = s.head
+	n.prev = nil
+	if s.head != nil {
+		s.head.prev = n
+	}
+	s.head = n
+	if s.tail == nil {
+		s.tail = n
+	}
+}
+
+func (s *sieveCachePolicy) removeLocked(n *sieveNode) {
+	if n.prev != nil {
+		n.prev.next = n.next
+	} else {
+		s.head = n.next
+	}
+	if n.next != nil {
+		n.next.prev = n.prev
+	} else {
+		s.tail = n.prev
+	}
+	n.prev, n.next = nil, nil
+}
+
+// evictLocked walks the hand from tail toward head, clearing visited bits as
+// it goes, until it finds an unvisited node to evict. Capacity being finite
+// guarantees this terminates: a full sweep zeroes every visited bit, so the
+// next pass over the same nodes evicts immediately.
+func (s *sieveCachePolicy) evictLocked() {
+	obj := s.hand
+	if obj == nil {
+		obj = s.tail
+	}
+	for obj != nil && obj.visited {
+		obj.visited = false
+		obj = obj.prev
+		if obj == nil {
+			obj = s.tail
+		}
+	}
+	if obj == nil {
+		return
+	}
+	s.hand = obj.prev
+	delete(s.index, obj.key)
+	s.removeLocked(obj)
+}